@@ -0,0 +1,128 @@
+package cryptor
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// CalibrationResult is the Option returned by Calibrate: applying it sets a
+// cryptor's cipher and cost parameters to the ones Calibrate chose, and its
+// exported fields let callers inspect what was selected (e.g. for logging)
+// without a separate side channel.
+type CalibrationResult struct {
+	Cipher    string
+	CostPower uint
+	R         int
+	P         int
+	Measured  time.Duration
+}
+
+func (cr *CalibrationResult) apply(o *options) {
+	o.cipher = cr.Cipher
+	o.costPower = cr.CostPower
+	o.scryptR = cr.R
+	o.scryptP = cr.P
+}
+
+// Calibrate benchmarks the host and returns an Option that sets cipherName,
+// a cost power and (for scrypt) the R and P parameters so that key
+// derivation with cipherName takes approximately target. It starts from a
+// low N (2^14) with R=8, P=1, doubles N while the measured time stays below
+// target and the scrypt memory requirement (128*N*R bytes) stays under
+// memoryLimitMB, and falls back to increasing P once a further doubling of N
+// would exceed the memory limit; R is always 8. It returns the largest power
+// of two whose measured time is at or below target. The cost power is never
+// set below 14, so on a host where 2^14 already takes longer than target the
+// result is 2^14 and Measured exceeds target. The returned Option is a
+// *CalibrationResult, so callers that want to log the chosen parameters can
+// type-assert it to read Cipher/CostPower/R/P/Measured.
+func Calibrate(cipherName string, target time.Duration, memoryLimitMB int) (Option, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	passphrase := []byte("calibration")
+
+	const minCostPower = 14
+	costPower := uint(minCostPower)
+	r := 8
+	p := 1
+	memoryLimitBytes := int64(memoryLimitMB) * 1024 * 1024
+
+	for {
+		n := 1 << costPower
+		measured, err := measureKDF(cipherName, passphrase, salt, n, r, p)
+		if err != nil {
+			return nil, err
+		}
+		if measured > target {
+			if p > 1 {
+				p--
+			} else if costPower > minCostPower {
+				costPower--
+			}
+			break
+		}
+
+		nextN := int64(1) << (costPower + 1)
+		if cipherName == "scrypt" && 128*nextN*int64(r) > memoryLimitBytes {
+			p++
+			continue
+		}
+		costPower++
+	}
+
+	// Re-measure at the chosen parameters: the loop's last measurement may
+	// be from a costPower that was rejected for exceeding target, which
+	// would otherwise leave Measured describing the wrong parameters.
+	measured, err := measureKDF(cipherName, passphrase, salt, 1<<costPower, r, p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CalibrationResult{
+		Cipher:    cipherName,
+		CostPower: costPower,
+		R:         r,
+		P:         p,
+		Measured:  measured,
+	}, nil
+}
+
+// measureKDF runs a single KDF derivation with the given parameters and
+// returns how long it took.
+func measureKDF(cipherName string, passphrase, salt []byte, n, r, p int) (time.Duration, error) {
+	start := time.Now()
+	switch cipherName {
+	case "scrypt":
+		if _, err := scrypt.Key(passphrase, salt, n, r, p, 32); err != nil {
+			return 0, fmt.Errorf("calibration scrypt call failed: %w", err)
+		}
+	case "pbkdf2":
+		pbkdf2.Key(passphrase, salt, n, 32, sha256.New)
+	default:
+		return 0, fmt.Errorf("unsupported cipher %q", cipherName)
+	}
+	return time.Since(start), nil
+}
+
+// WithScryptR sets the scrypt R (block size) parameter, overriding the
+// default of 8. It has no effect when the "pbkdf2" cipher is selected.
+func WithScryptR(r int) Option {
+	return optionFunc(func(o *options) {
+		o.scryptR = r
+	})
+}
+
+// WithScryptP sets the scrypt P (parallelization) parameter, overriding the
+// default of 1. It has no effect when the "pbkdf2" cipher is selected.
+func WithScryptP(p int) Option {
+	return optionFunc(func(o *options) {
+		o.scryptP = p
+	})
+}
@@ -0,0 +1,28 @@
+package cryptor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+)
+
+// newStreamChecksum returns a hash.Hash that accumulates a keystore
+// checksum incrementally: callers write each ciphertext chunk to it in
+// order and compare the final Sum against the checksum message. This
+// supports both the original "sha256" function (sha256(key||ciphertext),
+// with key pre-seeded here) and the "hmac-sha256" function introduced for
+// streaming use, which is HMAC-SHA256 keyed on key and offers the same
+// incremental verification without sha256's length-extension weakness.
+func newStreamChecksum(function string, key []byte) (hash.Hash, error) {
+	switch function {
+	case "", "sha256":
+		h := sha256.New()
+		h.Write(key)
+		return h, nil
+	case "hmac-sha256":
+		return hmac.New(sha256.New, key), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum %q", function)
+	}
+}
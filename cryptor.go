@@ -47,6 +47,9 @@ const (
 type options struct {
 	cipher    string
 	costPower uint
+	scryptR   int
+	scryptP   int
+	checksum  string
 }
 
 type optionFunc func(*options)
@@ -65,6 +68,19 @@ func WithCipher(cipher string) Option {
 	})
 }
 
+// WithChecksum sets the checksum function used to authenticate the
+// ciphertext, overriding the default of "sha256" (the standard EIP-2335
+// checksum, computed as sha256(key[16:32] || ciphertext)). Passing
+// "hmac-sha256" instead selects HMAC-SHA256 keyed on key[16:32]; the output
+// is then not a standard EIP-2335 keystore. EncryptStream/DecryptStream
+// compute and verify either checksum incrementally as ciphertext chunks
+// are streamed.
+func WithChecksum(function string) Option {
+	return optionFunc(func(opt *options) {
+		opt.checksum = function
+	})
+}
+
 // WithCost sets the cipher key cost for the encryptor to 2^power overriding
 // the default value of 18 (ie. 2^18=262144). Higher values increases the
 // cost of an exhaustive search but makes encoding and decoding proportionally slower.
@@ -79,25 +95,36 @@ func WithCost(t *testing.T, costPower uint) Option {
 }
 
 type Cryptor struct {
-	cipher string
-	cost   int
+	cipher   string
+	cost     int
+	scryptR  int
+	scryptP  int
+	checksum string
 }
 
 // NewEncryptor creates a new keystore V4 encryptor.
 // This takes the following options:
 // - cipher: the cipher to use when encrypting the secret, can be either "pbkdf2" (default) or "scrypt"
 // - costPower: the cipher key cost to use as power of 2, default is 18 (ie. 2^18).
+// - scryptR/scryptP: the scrypt R and P parameters, defaults are 8 and 1 respectively. Ignored for "pbkdf2".
+// - checksum: the checksum function used to authenticate the ciphertext, default is "sha256". See WithChecksum.
 func NewCryptor(opts ...Option) *Cryptor {
 	defaultOpt := options{
 		cipher:    "pbkdf2",
 		costPower: 18,
+		scryptR:   8,
+		scryptP:   1,
+		checksum:  "sha256",
 	}
 	for _, op := range opts {
 		op.apply(&defaultOpt)
 	}
 	return &Cryptor{
-		cipher: defaultOpt.cipher,
-		cost:   1 << defaultOpt.costPower,
+		cipher:   defaultOpt.cipher,
+		cost:     1 << defaultOpt.costPower,
+		scryptR:  defaultOpt.scryptR,
+		scryptP:  defaultOpt.scryptP,
+		checksum: defaultOpt.checksum,
 	}
 }
 
@@ -0,0 +1,65 @@
+package cryptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypt encrypts the secret provided, returning a keystore V4
+// representation. By default the checksum is "sha256", so the output is a
+// standard EIP-2335 keystore readable by other implementations; see
+// WithChecksum to opt in to the incrementally-verifiable "hmac-sha256"
+// checksum instead. Encrypt is a thin wrapper around EncryptStream for
+// callers that already hold the secret in memory; for large secrets use
+// EncryptStream directly.
+func (c *Cryptor) Encrypt(secret []byte, passphrase string) (map[string]interface{}, error) {
+	var ct bytes.Buffer
+	meta, err := c.EncryptStream(bytes.NewReader(secret), passphrase, &ct)
+	if err != nil {
+		return nil, err
+	}
+	cipherMeta, ok := meta["cipher"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("failed to generate keystore")
+	}
+	cipherMeta["message"] = hex.EncodeToString(ct.Bytes())
+	return meta, nil
+}
+
+// deriveEncryptionKey derives an encryption key for the given salt and
+// passphrase under the cryptor's configured cipher and cost, returning the
+// key alongside the KDF params that describe how it was derived so they
+// can be embedded in the resulting keystore.
+func (c *Cryptor) deriveEncryptionKey(salt []byte, passphrase string) ([]byte, *ksKDFParams, error) {
+	normedPassphrase := []byte(normPassphrase(passphrase))
+	kdfParams := &ksKDFParams{
+		Salt:  hex.EncodeToString(salt),
+		DKLen: 32,
+	}
+
+	var encryptionKey []byte
+	var err error
+	switch c.cipher {
+	case "scrypt":
+		kdfParams.N = c.cost
+		kdfParams.R = c.scryptR
+		kdfParams.P = c.scryptP
+		encryptionKey, err = scrypt.Key(normedPassphrase, salt, c.cost, c.scryptR, c.scryptP, 32)
+	case "pbkdf2":
+		kdfParams.C = c.cost
+		kdfParams.PRF = "hmac-sha256"
+		encryptionKey = pbkdf2.Key(normedPassphrase, salt, c.cost, 32, sha256.New)
+	default:
+		return nil, nil, fmt.Errorf("unsupported cipher %q", c.cipher)
+	}
+	if err != nil {
+		return nil, nil, errors.New("invalid KDF parameters")
+	}
+	return encryptionKey, kdfParams, nil
+}
@@ -0,0 +1,63 @@
+package cryptor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LoadKeystore reads keystore JSON from the file at path and unmarshals it
+// in to a map. If the file does not contain valid JSON the returned error
+// is annotated with the file name and the line and column of the syntax
+// error, rather than the opaque message returned by the JSON decoder.
+func LoadKeystore(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore file %s: %w", path, err)
+	}
+	ks := make(map[string]interface{})
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return nil, annotateJSONError(path, data, err)
+	}
+	return ks, nil
+}
+
+// DecryptFile reads keystore JSON from the file at path and decrypts it,
+// returning the secret. It is a convenience wrapper around LoadKeystore and
+// Decrypt.
+func (c *Cryptor) DecryptFile(path, passphrase string) ([]byte, error) {
+	ks, err := LoadKeystore(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decrypt(ks, passphrase)
+}
+
+// annotateJSONError rewrites a JSON decoding error to include the file name
+// and the line and column at which the error occurred, computed by walking
+// data up to the error's byte offset.
+func annotateJSONError(path string, data []byte, err error) error {
+	syntaxErr, ok := err.(*json.SyntaxError)
+	if !ok {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	line, col := lineAndColumn(data, syntaxErr.Offset)
+	return fmt.Errorf("%s:%d:%d: %s", path, line, col, syntaxErr.Error())
+}
+
+// lineAndColumn returns the 1-based line and column corresponding to the
+// given byte offset in data.
+func lineAndColumn(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	prefix := data[:offset]
+	line = bytes.Count(prefix, []byte("\n")) + 1
+	if idx := bytes.LastIndexByte(prefix, '\n'); idx >= 0 {
+		col = len(prefix) - idx
+	} else {
+		col = len(prefix) + 1
+	}
+	return line, col
+}
@@ -0,0 +1,149 @@
+package cryptor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// web3CipherParams holds the cipher parameters found in a Web3 Secret
+// Storage v1/v3 keystore.
+type web3CipherParams struct {
+	IV string `json:"iv"`
+}
+
+// web3Crypto holds the flat "crypto" (v3) or "Crypto" (v1) object found in a
+// Web3 Secret Storage keystore.
+type web3Crypto struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams web3CipherParams       `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+// web3Keystore holds the fields common to v1 and v3 Web3 Secret Storage
+// keystores that we need in order to detect and decrypt them.
+type web3Keystore struct {
+	Version  int         `json:"version"`
+	Crypto   *web3Crypto `json:"crypto"`
+	CryptoV1 *web3Crypto `json:"Crypto"`
+}
+
+// DecryptAny decrypts the data provided, transparently handling the current
+// EIP-2335 keystore V4 layout as well as the legacy Ethereum Web3 Secret
+// Storage v1 and v3 formats. Version is detected from the top-level
+// "version" field and the presence of a flat "crypto"/"Crypto" object.
+func (c *Cryptor) DecryptAny(data map[string]interface{}, passphrase string) ([]byte, error) {
+	if data == nil {
+		return nil, errors.New("no data supplied")
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.New("failed to parse keystore")
+	}
+	web3ks := &web3Keystore{}
+	if err := json.Unmarshal(b, web3ks); err != nil {
+		return nil, errors.New("failed to parse keystore")
+	}
+
+	switch {
+	case web3ks.Version == 4:
+		return c.Decrypt(data, passphrase)
+	case web3ks.Version == 3 && web3ks.Crypto != nil:
+		return decryptWeb3V3(web3ks.Crypto, passphrase)
+	case web3ks.Version == 1 && web3ks.CryptoV1 != nil:
+		return decryptWeb3V1(web3ks.CryptoV1, passphrase)
+	case web3ks.Crypto != nil:
+		return decryptWeb3V3(web3ks.Crypto, passphrase)
+	case web3ks.CryptoV1 != nil:
+		return decryptWeb3V1(web3ks.CryptoV1, passphrase)
+	default:
+		return c.Decrypt(data, passphrase)
+	}
+}
+
+// web3DerivedKey derives the decryption key for a Web3 Secret Storage
+// crypto object, honouring its kdf/kdfparams. It dispatches through the
+// same kdfRegistry used for keystore V4 so that a PRF or KDF registered
+// for one format (see RegisterKDF) is available to the other.
+func web3DerivedKey(crypto *web3Crypto, passphrase []byte) ([]byte, error) {
+	kdfFn, ok := kdfRegistry[crypto.KDF]
+	if !ok {
+		return nil, fmt.Errorf("unsupported KDF %q", crypto.KDF)
+	}
+	return kdfFn(crypto.KDFParams, passphrase)
+}
+
+// decryptWeb3 decrypts a Web3 Secret Storage "crypto"/"Crypto" object
+// common to the v1 and v3 formats: it derives the key, verifies the
+// Keccak-256 MAC (keccak256(key[16:32] || ciphertext), distinct from the
+// checksum used by keystore V4), then dispatches the cipher through the
+// same cipherRegistry used for keystore V4.
+func decryptWeb3(crypto *web3Crypto, passphrase string) ([]byte, error) {
+	key, err := web3DerivedKey(crypto, []byte(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) < 32 {
+		return nil, errors.New("decryption key must be at least 32 bytes")
+	}
+	cipherMsg, err := hex.DecodeString(crypto.CipherText)
+	if err != nil {
+		return nil, errors.New("invalid cipher message")
+	}
+
+	mac, err := hex.DecodeString(crypto.MAC)
+	if err != nil {
+		return nil, errors.New("invalid mac")
+	}
+	computed := sha3.NewLegacyKeccak256()
+	computed.Write(key[16:32])
+	computed.Write(cipherMsg)
+	if !bytes.Equal(computed.Sum(nil), mac) {
+		return nil, errors.New("invalid mac")
+	}
+
+	cipherParams, err := toParamsMap(crypto.CipherParams)
+	if err != nil {
+		return nil, errors.New("invalid cipher parameters")
+	}
+	cipherFn, ok := cipherRegistry[crypto.Cipher]
+	if !ok {
+		return nil, fmt.Errorf("unsupported cipher %q", crypto.Cipher)
+	}
+	return cipherFn(key, cipherParams, cipherMsg)
+}
+
+// decryptWeb3V3 decrypts a Web3 Secret Storage v3 "crypto" object.
+func decryptWeb3V3(crypto *web3Crypto, passphrase string) ([]byte, error) {
+	return decryptWeb3(crypto, passphrase)
+}
+
+// decryptWeb3V1 decrypts a Web3 Secret Storage v1 "Crypto" object.
+func decryptWeb3V1(crypto *web3Crypto, passphrase string) ([]byte, error) {
+	return decryptWeb3(crypto, passphrase)
+}
+
+// pkcs7Unpad removes PKCS#7 padding from data.
+func pkcs7Unpad(data []byte) ([]byte, error) {
+	length := len(data)
+	if length == 0 {
+		return nil, errors.New("invalid padding")
+	}
+	padLen := int(data[length-1])
+	if padLen == 0 || padLen > length {
+		return nil, errors.New("invalid padding")
+	}
+	for _, b := range data[length-padLen:] {
+		if int(b) != padLen {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return data[:length-padLen], nil
+}
@@ -0,0 +1,153 @@
+package cryptor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDFFunc derives a decryption/encryption key from the raw KDF params map
+// (as found under the keystore's "kdf"/"params" entry, or "kdfparams" in a
+// Web3 Secret Storage keystore) and a passphrase, which is normalised for
+// keystore V4 and passed as given for Web3 Secret Storage v1/v3.
+type KDFFunc func(params map[string]interface{}, passphrase []byte) ([]byte, error)
+
+// CipherFunc decrypts ciphertext under the given key and raw cipher params
+// map (as found under the keystore's "cipher"/"params" entry).
+type CipherFunc func(key []byte, params map[string]interface{}, ciphertext []byte) ([]byte, error)
+
+var (
+	kdfRegistry    = make(map[string]KDFFunc)
+	cipherRegistry = make(map[string]CipherFunc)
+)
+
+// RegisterKDF registers a KDF function under the given name, as it appears
+// in a keystore's "kdf"/"function" field. Registering under a name that is
+// already registered replaces the existing entry, allowing callers to
+// override the built-in KDFs.
+func RegisterKDF(name string, fn KDFFunc) {
+	kdfRegistry[name] = fn
+}
+
+// RegisterCipher registers a cipher function under the given name, as it
+// appears in a keystore's "cipher"/"function" field. Registering under a
+// name that is already registered replaces the existing entry, allowing
+// callers to override the built-in ciphers.
+func RegisterCipher(name string, fn CipherFunc) {
+	cipherRegistry[name] = fn
+}
+
+func init() {
+	RegisterKDF("scrypt", scryptKDF)
+	RegisterKDF("pbkdf2", pbkdf2KDF)
+	RegisterCipher("aes-128-ctr", aesCTRCipher(16))
+	RegisterCipher("aes-256-ctr", aesCTRCipher(32))
+	RegisterCipher("aes-128-cbc", aes128CBCCipher)
+}
+
+func paramFloat(params map[string]interface{}, key string) int {
+	v, _ := params[key].(float64)
+	return int(v)
+}
+
+func paramString(params map[string]interface{}, key string) string {
+	v, _ := params[key].(string)
+	return v
+}
+
+func scryptKDF(params map[string]interface{}, passphrase []byte) ([]byte, error) {
+	salt, err := hex.DecodeString(paramString(params, "salt"))
+	if err != nil {
+		return nil, errors.New("invalid KDF salt")
+	}
+	dkLen := paramFloat(params, "dklen")
+	key, err := scrypt.Key(passphrase, salt, paramFloat(params, "n"), paramFloat(params, "r"), paramFloat(params, "p"), dkLen)
+	if err != nil {
+		return nil, errors.New("invalid KDF parameters")
+	}
+	return key, nil
+}
+
+func pbkdf2PRF(prf string) (func() hash.Hash, error) {
+	switch prf {
+	case "hmac-sha256":
+		return sha256.New, nil
+	case "hmac-sha384":
+		return sha512.New384, nil
+	case "hmac-sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 PRF %q", prf)
+	}
+}
+
+func pbkdf2KDF(params map[string]interface{}, passphrase []byte) ([]byte, error) {
+	salt, err := hex.DecodeString(paramString(params, "salt"))
+	if err != nil {
+		return nil, errors.New("invalid KDF salt")
+	}
+	prf, err := pbkdf2PRF(paramString(params, "prf"))
+	if err != nil {
+		return nil, err
+	}
+	dkLen := paramFloat(params, "dklen")
+	return pbkdf2.Key(passphrase, salt, paramFloat(params, "c"), dkLen, prf), nil
+}
+
+// aesCTRCipher returns a CipherFunc that decrypts with AES-CTR using the
+// first keyLen bytes of the supplied key.
+func aesCTRCipher(keyLen int) CipherFunc {
+	return func(key []byte, params map[string]interface{}, ciphertext []byte) ([]byte, error) {
+		xorKeyStream, err := ctrKeyStreamer(key, params, keyLen)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]byte, len(ciphertext))
+		xorKeyStream(res, ciphertext)
+		return res, nil
+	}
+}
+
+func aes128CBCCipher(key []byte, params map[string]interface{}, ciphertext []byte) ([]byte, error) {
+	if len(key) < 16 {
+		return nil, errors.New("decryption key must be at least 16 bytes")
+	}
+	aesCipher, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(paramString(params, "iv"))
+	if err != nil {
+		return nil, errors.New("invalid IV")
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("ciphertext is not a multiple of the block size")
+	}
+	padded := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(aesCipher, iv).CryptBlocks(padded, ciphertext)
+	return pkcs7Unpad(padded)
+}
+
+// toParamsMap round-trips v through JSON to produce a generic params map,
+// so that typed structs (e.g. *ksKDFParams, *ksCipherParams) can be passed
+// to registry functions that expect the raw keystore representation.
+func toParamsMap(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	params := make(map[string]interface{})
+	if err := json.Unmarshal(b, &params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
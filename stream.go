@@ -0,0 +1,284 @@
+package cryptor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// streamChunkSize is the size of the buffer used to shuttle data between
+// the plaintext/ciphertext streams and the underlying AES-CTR keystream.
+const streamChunkSize = 64 * 1024
+
+// EncryptStream encrypts the secret read from secret, writing ciphertext to
+// ct as it is produced, and returns the keystore V4 metadata (KDF, cipher
+// and checksum information, but not the ciphertext itself) needed to
+// decrypt it later with DecryptStream. Because the secret and ciphertext
+// are streamed rather than buffered whole, this is suitable for payloads
+// too large to hold comfortably in memory, such as seed vaults or backup
+// bundles. The checksum is the cryptor's configured checksum function
+// (see WithChecksum), "sha256" by default for standard EIP-2335 output, or
+// "hmac-sha256" if selected; DecryptStream verifies either one
+// incrementally as ciphertext chunks arrive.
+func (c *Cryptor) EncryptStream(secret io.Reader, passphrase string, ct io.Writer) (map[string]interface{}, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	iv := make([]byte, 16)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	encryptionKey, kdfParams, err := c.deriveEncryptionKey(salt, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherParams := &ksCipherParams{IV: hex.EncodeToString(iv)}
+	cipherParamsMap, err := toParamsMap(cipherParams)
+	if err != nil {
+		return nil, errors.New("failed to generate keystore")
+	}
+	xorKeyStream, err := ctrKeyStreamer(encryptionKey, cipherParamsMap, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := newStreamChecksum(c.checksum, encryptionKey[16:32])
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, rerr := secret.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			xorKeyStream(chunk, chunk)
+			mac.Write(chunk)
+			if _, werr := ct.Write(chunk); werr != nil {
+				return nil, werr
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return nil, rerr
+		}
+	}
+
+	ks := &keystoreV4{
+		KDF: &ksKDF{
+			Function: c.cipher,
+			Params:   kdfParams,
+		},
+		Checksum: &ksChecksum{
+			Function: c.checksum,
+			Params:   map[string]interface{}{},
+			Message:  hex.EncodeToString(mac.Sum(nil)),
+		},
+		Cipher: &ksCipher{
+			Function: "aes-128-ctr",
+			Params:   cipherParams,
+		},
+	}
+
+	b, err := json.Marshal(ks)
+	if err != nil {
+		return nil, errors.New("failed to generate keystore")
+	}
+	res := make(map[string]interface{})
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, errors.New("failed to generate keystore")
+	}
+	return res, nil
+}
+
+// resettable is implemented by io.Writers, such as *bytes.Buffer, that can
+// discard previously written data so DecryptStream can retry a failed
+// attempt without leaking its output into the successful one.
+type resettable interface {
+	Reset()
+}
+
+// DecryptStream decrypts ciphertext read from ct into pt, using the KDF,
+// cipher and checksum metadata from meta (as produced by EncryptStream;
+// the "message" field of meta's cipher object, if any, is ignored in
+// favour of ct). The checksum is verified as ciphertext is read, but since
+// AES-CTR is not an authenticated cipher the corresponding plaintext is
+// written to pt as each chunk is decrypted; if DecryptStream returns an
+// error, callers must treat anything already written to pt as unverified
+// and discard it. If the attempt with the standard passphrase normalisation
+// returns any error and both ct implements io.Seeker and pt implements
+// Reset(), DecryptStream rewinds ct, discards pt's partial output and
+// retries with the alternate normalisation (Decrypt always qualifies, as it
+// passes a *bytes.Reader and a *bytes.Buffer); otherwise the first
+// attempt's error is returned as-is.
+func (c *Cryptor) DecryptStream(meta map[string]interface{}, passphrase string, ct io.Reader, pt io.Writer) error {
+	if meta == nil {
+		return errors.New("no data supplied")
+	}
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return errors.New("failed to parse keystore")
+	}
+	ks := &keystoreV4{}
+	if err := json.Unmarshal(b, ks); err != nil {
+		return errors.New("failed to parse keystore")
+	}
+	if ks.Checksum == nil {
+		return errors.New("no checksum")
+	}
+	if ks.Cipher == nil {
+		return errors.New("no cipher")
+	}
+
+	normedPassphrase := []byte(normPassphrase(passphrase))
+	err = decryptStreamNorm(ks, normedPassphrase, ct, pt)
+	if err == nil {
+		return nil
+	}
+
+	seeker, ok := ct.(io.Seeker)
+	if !ok {
+		return err
+	}
+	resetter, ok := pt.(resettable)
+	if !ok {
+		return err
+	}
+	if _, serr := seeker.Seek(0, io.SeekStart); serr != nil {
+		return err
+	}
+	resetter.Reset()
+	normedPassphrase = []byte(altNormPassphrase(passphrase))
+	return decryptStreamNorm(ks, normedPassphrase, ct, pt)
+}
+
+func decryptStreamNorm(ks *keystoreV4, normedPassphrase []byte, ct io.Reader, pt io.Writer) error {
+	var decryptionKey []byte
+	if ks.KDF == nil {
+		decryptionKey = normedPassphrase
+	} else {
+		kdfFn, ok := kdfRegistry[ks.KDF.Function]
+		if !ok {
+			return fmt.Errorf("unsupported KDF %q", ks.KDF.Function)
+		}
+		kdfParams, err := toParamsMap(ks.KDF.Params)
+		if err != nil {
+			return errors.New("invalid KDF parameters")
+		}
+		decryptionKey, err = kdfFn(kdfParams, normedPassphrase)
+		if err != nil {
+			return err
+		}
+	}
+	if len(decryptionKey) < 32 {
+		return errors.New("decryption key must be at least 32 bytes")
+	}
+
+	checksumMsg, err := hex.DecodeString(ks.Checksum.Message)
+	if err != nil {
+		return errors.New("invalid checksum message")
+	}
+	mac, err := newStreamChecksum(ks.Checksum.Function, decryptionKey[16:32])
+	if err != nil {
+		return err
+	}
+
+	cipherParams, err := toParamsMap(ks.Cipher.Params)
+	if err != nil {
+		return errors.New("invalid cipher parameters")
+	}
+
+	xorKeyStream, streamErr := streamCipherFor(ks.Cipher.Function, decryptionKey, cipherParams)
+	if streamErr != nil {
+		// Not every registered cipher can be decrypted a chunk at a time
+		// (e.g. "aes-128-cbc" needs the whole ciphertext to unpad), so fall
+		// back to the cipher's registered CipherFunc, buffering the
+		// ciphertext for this one call.
+		cipherFn, ok := cipherRegistry[ks.Cipher.Function]
+		if !ok {
+			return streamErr
+		}
+		cipherMsg, err := io.ReadAll(ct)
+		if err != nil {
+			return err
+		}
+		mac.Write(cipherMsg)
+		if !hmac.Equal(mac.Sum(nil), checksumMsg) {
+			return errors.New("invalid checksum")
+		}
+		plain, err := cipherFn(decryptionKey, cipherParams, cipherMsg)
+		if err != nil {
+			return err
+		}
+		if _, err := pt.Write(plain); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, rerr := ct.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			mac.Write(chunk)
+			xorKeyStream(chunk, chunk)
+			if _, werr := pt.Write(chunk); werr != nil {
+				return werr
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+
+	if !hmac.Equal(mac.Sum(nil), checksumMsg) {
+		return errors.New("invalid checksum")
+	}
+	return nil
+}
+
+// streamCipherFor returns a chunk-wise XORKeyStream function for one of the
+// streaming-capable (i.e. block-boundary-free) registered ciphers. Ciphers
+// that require whole-message framing, such as "aes-128-cbc", are not
+// streamable and are rejected here.
+func streamCipherFor(name string, key []byte, params map[string]interface{}) (func(dst, src []byte), error) {
+	switch name {
+	case "aes-128-ctr":
+		return ctrKeyStreamer(key, params, 16)
+	case "aes-256-ctr":
+		return ctrKeyStreamer(key, params, 32)
+	default:
+		return nil, fmt.Errorf("unsupported streaming cipher %q", name)
+	}
+}
+
+func ctrKeyStreamer(key []byte, params map[string]interface{}, keyLen int) (func(dst, src []byte), error) {
+	if len(key) < keyLen {
+		return nil, fmt.Errorf("decryption key must be at least %d bytes", keyLen)
+	}
+	aesCipher, err := aes.NewCipher(key[:keyLen])
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hex.DecodeString(paramString(params, "iv"))
+	if err != nil {
+		return nil, errors.New("invalid IV")
+	}
+	stream := cipher.NewCTR(aesCipher, iv)
+	return stream.XORKeyStream, nil
+}